@@ -0,0 +1,170 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataconnect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AutoIP selects which Cloud SQL connector IP type to dial.
+type AutoIP string
+
+const (
+	// AutoIPPublic dials the instance's public IP. This is the connector's
+	// own default and is equivalent to leaving AutoIP unset.
+	AutoIPPublic AutoIP = "public"
+	// AutoIPPrivate dials the instance's private IP.
+	AutoIPPrivate AutoIP = "private"
+	// AutoIPPSC dials the instance via Private Service Connect.
+	AutoIPPSC AutoIP = "psc"
+)
+
+// Logger is the minimal logging hook dataconnect calls into for
+// connection lifecycle events. A standard library *log.Logger satisfies
+// this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Config holds everything needed to open a database connection pool. It
+// exposes the same knobs GetDB derives from environment variables, plus
+// pool tuning and observability options for callers that want to use
+// dataconnect as a library rather than through env vars.
+type Config struct {
+	// Engine selects the database driver: "postgres" (the default),
+	// "mysql", or "mssql".
+	Engine string
+
+	// User and Password are used directly unless CredentialProvider is
+	// set, in which case they are ignored. Password is not required when
+	// IAMAuthN is true.
+	User     string
+	Password string
+	// CredentialProvider, when set, overrides User and Password.
+	CredentialProvider CredentialProvider
+
+	DBName string
+
+	// InstanceConnectionName, when set (e.g. "project:region:instance"),
+	// connects via the Cloud SQL connector. UnixSocketPath takes precedence
+	// when both are set; if both are empty, TCPHost/TCPPort are used.
+	InstanceConnectionName string
+	AutoIP                 AutoIP
+	// IAMAuthN enables Cloud SQL IAM database authentication. When User
+	// is empty, the user is derived from the client_email of the
+	// Application Default Credentials JSON; Open returns an error for
+	// default credentials without one, such as metadata-server credentials.
+	IAMAuthN bool
+
+	TCPHost string
+	TCPPort string
+
+	// UnixSocketPath connects via a Unix domain socket, e.g.
+	// "/cloudsql/project:region:instance".
+	UnixSocketPath string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// DialTimeout bounds each dial through the Cloud SQL connector.
+	DialTimeout time.Duration
+	// RefreshTimeout bounds each certificate refresh performed by the
+	// Cloud SQL connector.
+	RefreshTimeout time.Duration
+
+	// Logger, if set, receives connection lifecycle log lines.
+	Logger Logger
+}
+
+// connectionDetails resolves cfg's credentials and converts it to the
+// internal shape the connect* functions operate on.
+func (cfg Config) connectionDetails(ctx context.Context) (connectionDetails, error) {
+	engine := cfg.Engine
+	if engine == "" {
+		engine = enginePostgres
+	}
+
+	dbUser, dbPwd := cfg.User, cfg.Password
+	switch {
+	case cfg.CredentialProvider != nil:
+		var err error
+		dbUser, err = cfg.CredentialProvider.Username(ctx)
+		if err != nil {
+			return connectionDetails{}, fmt.Errorf("resolving db user: %w", err)
+		}
+		dbPwd, err = cfg.CredentialProvider.Password(ctx)
+		if err != nil {
+			return connectionDetails{}, fmt.Errorf("resolving db password: %w", err)
+		}
+	case cfg.IAMAuthN && dbUser == "":
+		var err error
+		dbUser, err = iamUserFromDefaultCredentials(ctx)
+		if err != nil {
+			return connectionDetails{}, fmt.Errorf("deriving IAM db user: %w", err)
+		}
+	}
+
+	return connectionDetails{
+		dbEngine:               engine,
+		dbUser:                 dbUser,
+		dbPwd:                  dbPwd,
+		dbName:                 cfg.DBName,
+		instanceConnectionName: cfg.InstanceConnectionName,
+		autoIP:                 cfg.AutoIP,
+		dbTCPHost:              cfg.TCPHost,
+		dbPort:                 cfg.TCPPort,
+		unixSocketPath:         cfg.UnixSocketPath,
+		iamAuthN:               cfg.IAMAuthN,
+		dialTimeout:            cfg.DialTimeout,
+		refreshTimeout:         cfg.RefreshTimeout,
+		logger:                 cfg.Logger,
+	}, nil
+}
+
+// Open creates a connection pool according to cfg. Unlike GetDB, it does
+// not cache its result in a package-level singleton, so it may be called
+// more than once, e.g. to connect to several databases from one process.
+func Open(ctx context.Context, cfg Config) (*sql.DB, error) {
+	start := time.Now()
+	cd, err := cfg.connectionDetails(ctx)
+
+	var pool *sql.DB
+	if err == nil {
+		pool, err = connect(cd)
+	}
+	recordConnect(ctx, start, cd.dbEngine, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		pool.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		pool.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return pool, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// CredentialProvider resolves the database username and password used to
+// open a connection. GetDB and GetDBFor consult the provider installed
+// with SetCredentialProvider instead of the DB_USER and DB_PASS
+// environment variables, except when IAM_AUTH is "true": they then take
+// the user from DB_USER (or the default credentials) and send no password.
+type CredentialProvider interface {
+	Username(ctx context.Context) (string, error)
+	Password(ctx context.Context) (string, error)
+}
+
+var (
+	credentialProviderMu sync.RWMutex
+	credentialProvider   CredentialProvider = envCredentialProvider{}
+)
+
+// SetCredentialProvider installs p as the source of database credentials
+// for the GetDB or GetDBFor call that opens the package's pool, replacing
+// the default env-var provider. It has no effect on a pool that is already
+// open. Implementations may wrap Vault, AWS Secrets Manager, or any other
+// secret store.
+func SetCredentialProvider(p CredentialProvider) {
+	credentialProviderMu.Lock()
+	defer credentialProviderMu.Unlock()
+	credentialProvider = p
+}
+
+func getCredentialProvider() CredentialProvider {
+	credentialProviderMu.RLock()
+	defer credentialProviderMu.RUnlock()
+	return credentialProvider
+}
+
+// envCredentialProvider reads the database username and password from the
+// DB_USER and DB_PASS environment variables. It is the default provider
+// and matches dataconnect's original behavior.
+type envCredentialProvider struct{}
+
+func (envCredentialProvider) Username(ctx context.Context) (string, error) {
+	return mustGetenv("DB_USER"), nil
+}
+
+func (envCredentialProvider) Password(ctx context.Context) (string, error) {
+	return mustGetenv("DB_PASS"), nil
+}
+
+// SecretManagerCredentialProvider resolves the database username and
+// password from Google Cloud Secret Manager, given resource URIs of the
+// form "sm://projects/*/secrets/*/versions/*".
+type SecretManagerCredentialProvider struct {
+	client       *secretmanager.Client
+	userURI      string
+	passURI      string
+	refreshEvery time.Duration
+
+	mu       sync.RWMutex
+	user     string
+	password string
+}
+
+// SecretManagerOption configures a SecretManagerCredentialProvider.
+type SecretManagerOption func(*SecretManagerCredentialProvider)
+
+// WithRefreshInterval causes the provider to re-fetch both secrets from
+// Secret Manager on the given interval, until the context passed to
+// NewSecretManagerCredentialProvider is done, so Username and Password
+// return rotated secret versions without restarting the process. A pool
+// that is already open keeps the credentials it was opened with.
+func WithRefreshInterval(d time.Duration) SecretManagerOption {
+	return func(p *SecretManagerCredentialProvider) {
+		p.refreshEvery = d
+	}
+}
+
+// NewSecretManagerCredentialProvider creates a CredentialProvider that
+// resolves userURI and passURI (each "sm://projects/*/secrets/*/versions/*")
+// via Secret Manager, fetching both once before returning.
+func NewSecretManagerCredentialProvider(ctx context.Context, userURI, passURI string, opts ...SecretManagerOption) (*SecretManagerCredentialProvider, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("secretmanager.NewClient: %w", err)
+	}
+
+	p := &SecretManagerCredentialProvider{
+		client:  client,
+		userURI: userURI,
+		passURI: passURI,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if err := p.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if p.refreshEvery > 0 {
+		go p.refreshLoop(ctx)
+	}
+	return p, nil
+}
+
+func (p *SecretManagerCredentialProvider) refresh(ctx context.Context) error {
+	user, err := p.accessSecret(ctx, p.userURI)
+	if err != nil {
+		return fmt.Errorf("resolving db user secret: %w", err)
+	}
+	password, err := p.accessSecret(ctx, p.passURI)
+	if err != nil {
+		return fmt.Errorf("resolving db password secret: %w", err)
+	}
+
+	p.mu.Lock()
+	p.user = user
+	p.password = password
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *SecretManagerCredentialProvider) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.refreshEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.refresh(ctx); err != nil {
+				continue
+			}
+		}
+	}
+}
+
+// accessSecret fetches the payload of a "sm://projects/*/secrets/*/versions/*"
+// resource URI.
+func (p *SecretManagerCredentialProvider) accessSecret(ctx context.Context, uri string) (string, error) {
+	const prefix = "sm://"
+	if len(uri) < len(prefix) || uri[:len(prefix)] != prefix {
+		return "", fmt.Errorf("invalid secret URI %q: must start with %q", uri, prefix)
+	}
+	resp, err := p.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
+		Name: uri[len(prefix):],
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Payload.Data), nil
+}
+
+func (p *SecretManagerCredentialProvider) Username(ctx context.Context) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.user, nil
+}
+
+func (p *SecretManagerCredentialProvider) Password(ctx context.Context) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.password, nil
+}
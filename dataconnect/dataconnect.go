@@ -22,10 +22,23 @@ import (
 	"net"
 	"os"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/cloudsqlconn"
+	"github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported values for the DB_ENGINE environment variable.
+const (
+	enginePostgres = "postgres"
+	engineMySQL    = "mysql"
+	engineMSSQL    = "mssql"
 )
 
 var (
@@ -34,13 +47,25 @@ var (
 )
 
 type connectionDetails struct {
+	dbEngine               string
 	dbUser                 string
 	dbPwd                  string
 	dbName                 string
 	instanceConnectionName string
-	usePrivate             string
+	autoIP                 AutoIP
 	dbTCPHost              string
 	dbPort                 string
+	unixSocketPath         string
+	iamAuthN               bool
+	dialTimeout            time.Duration
+	refreshTimeout         time.Duration
+	logger                 Logger
+}
+
+func (cd connectionDetails) logf(format string, v ...interface{}) {
+	if cd.logger != nil {
+		cd.logger.Printf(format, v...)
+	}
 }
 
 func mustGetenv(k string) string {
@@ -51,79 +76,221 @@ func mustGetenv(k string) string {
 	return v
 }
 
-func connectWithConnector(cd connectionDetails) (*sql.DB, error) {
-	dsn := fmt.Sprintf("user=%s password=%s database=%s", cd.dbUser, cd.dbPwd, cd.dbName)
-	config, err := pgx.ParseConfig(dsn)
-	if err != nil {
-		return nil, err
-	}
+// mssqlCloudSQLDialer adapts a cloudsqlconn.Dialer to the mssql.Dialer
+// interface so the SQL Server driver can connect through the Cloud SQL
+// connector.
+type mssqlCloudSQLDialer struct {
+	dialer                 *cloudsqlconn.Dialer
+	instanceConnectionName string
+	dialTimeout            time.Duration
+}
+
+func (d *mssqlCloudSQLDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	cd := connectionDetails{instanceConnectionName: d.instanceConnectionName, dialTimeout: d.dialTimeout}
+	return dialContext(ctx, cd, func(ctx context.Context) (net.Conn, error) {
+		return traceDial(ctx, d.instanceConnectionName, func(ctx context.Context) (net.Conn, error) {
+			return d.dialer.Dial(ctx, d.instanceConnectionName)
+		})
+	})
+}
+
+func newDialer(cd connectionDetails) (*cloudsqlconn.Dialer, error) {
+	ctx, span := tracer.Start(context.Background(), "dataconnect.Connect",
+		trace.WithAttributes(attribute.String("cloudsql.instance_connection_name", cd.instanceConnectionName)))
+	defer span.End()
 
 	var opts []cloudsqlconn.Option
-	if cd.usePrivate != "" {
+	switch cd.autoIP {
+	case AutoIPPrivate:
 		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPrivateIP()))
+	case AutoIPPSC:
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPSC()))
+	}
+	if cd.iamAuthN {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
 	}
-	d, err := cloudsqlconn.NewDialer(context.Background(), opts...)
+	if cd.refreshTimeout > 0 {
+		opts = append(opts, cloudsqlconn.WithRefreshTimeout(cd.refreshTimeout))
+	}
+	cd.logf("dataconnect: connecting to %s via the Cloud SQL connector", cd.instanceConnectionName)
+	d, err := cloudsqlconn.NewDialer(ctx, opts...)
 	if err != nil {
-		return nil, err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-	// Use the Cloud SQL connector to handle connecting to the instance.
-	// This approach does *NOT* require the Cloud SQL proxy.
-	config.DialFunc = func(ctx context.Context, network, instance string) (net.Conn, error) {
-		return d.Dial(ctx, cd.instanceConnectionName)
+	return d, err
+}
+
+// dialContext applies cd's DialTimeout, if any, before delegating to dial.
+func dialContext(ctx context.Context, cd connectionDetails, dial func(context.Context) (net.Conn, error)) (net.Conn, error) {
+	if cd.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cd.dialTimeout)
+		defer cancel()
 	}
-	dbURI := stdlib.RegisterConnConfig(config)
-	dbPool, err := sql.Open("pgx", dbURI)
+	return dial(ctx)
+}
+
+func connectWithConnector(cd connectionDetails) (*sql.DB, error) {
+	d, err := newDialer(cd)
 	if err != nil {
-		return nil, fmt.Errorf("sql.Open: %w", err)
+		return nil, err
+	}
+
+	switch cd.dbEngine {
+	case engineMySQL:
+		mysql.RegisterDialContext(cd.instanceConnectionName, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialContext(ctx, cd, func(ctx context.Context) (net.Conn, error) {
+				return traceDial(ctx, cd.instanceConnectionName, func(ctx context.Context) (net.Conn, error) {
+					return d.Dial(ctx, cd.instanceConnectionName)
+				})
+			})
+		})
+		dbURI := fmt.Sprintf("%s:%s@%s(%s)/%s", cd.dbUser, cd.dbPwd, cd.instanceConnectionName, cd.instanceConnectionName, cd.dbName)
+		dbPool, err := sql.Open("mysql", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	case engineMSSQL:
+		dsn := fmt.Sprintf("sqlserver://%s:%s@localhost?database=%s", cd.dbUser, cd.dbPwd, cd.dbName)
+		connector, err := mssql.NewConnector(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("mssql.NewConnector: %w", err)
+		}
+		connector.Dialer = &mssqlCloudSQLDialer{dialer: d, instanceConnectionName: cd.instanceConnectionName, dialTimeout: cd.dialTimeout}
+		return sql.OpenDB(connector), nil
+	default:
+		dsn := fmt.Sprintf("user=%s password=%s database=%s", cd.dbUser, cd.dbPwd, cd.dbName)
+		config, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		// Use the Cloud SQL connector to handle connecting to the instance.
+		// This approach does *NOT* require the Cloud SQL proxy.
+		config.DialFunc = func(ctx context.Context, network, instance string) (net.Conn, error) {
+			return dialContext(ctx, cd, func(ctx context.Context) (net.Conn, error) {
+				return traceDial(ctx, cd.instanceConnectionName, func(ctx context.Context) (net.Conn, error) {
+					return d.Dial(ctx, cd.instanceConnectionName)
+				})
+			})
+		}
+		dbURI := stdlib.RegisterConnConfig(config)
+		dbPool, err := sql.Open("pgx", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
 	}
-	return dbPool, nil
 }
 
 // connectTCPSocket initializes a TCP connection pool for a Cloud SQL
-// instance of Postgres.
+// instance of the configured engine.
 func connectTCPSocket(cd connectionDetails) (*sql.DB, error) {
-	dbURI := fmt.Sprintf("host=%s user=%s password=%s port=%s database=%s",
-		cd.dbTCPHost, cd.dbUser, cd.dbPwd, cd.dbPort, cd.dbName)
+	switch cd.dbEngine {
+	case engineMySQL:
+		dbURI := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", cd.dbUser, cd.dbPwd, cd.dbTCPHost, cd.dbPort, cd.dbName)
+		dbPool, err := sql.Open("mysql", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	case engineMSSQL:
+		dbURI := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", cd.dbUser, cd.dbPwd, cd.dbTCPHost, cd.dbPort, cd.dbName)
+		dbPool, err := sql.Open("mssql", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	default:
+		dbURI := fmt.Sprintf("host=%s user=%s password=%s port=%s database=%s",
+			cd.dbTCPHost, cd.dbUser, cd.dbPwd, cd.dbPort, cd.dbName)
 
-	// dbPool is the pool of database connections.
-	dbPool, err := sql.Open("pgx", dbURI)
-	if err != nil {
-		return nil, fmt.Errorf("sql.Open: %w", err)
+		// dbPool is the pool of database connections.
+		dbPool, err := sql.Open("pgx", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
 	}
+}
 
-	return dbPool, nil
+// connectUnixSocket initializes a Unix socket connection pool for a
+// Cloud SQL instance of the configured engine. This is the standard way
+// to reach Cloud SQL from App Engine Standard or Cloud Run when the
+// instance is mounted at /cloudsql.
+func connectUnixSocket(cd connectionDetails) (*sql.DB, error) {
+	switch cd.dbEngine {
+	case engineMySQL:
+		dbURI := fmt.Sprintf("%s:%s@unix(%s)/%s", cd.dbUser, cd.dbPwd, cd.unixSocketPath, cd.dbName)
+		dbPool, err := sql.Open("mysql", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	case engineMSSQL:
+		dbURI := fmt.Sprintf("sqlserver://%s:%s?database=%s&server=%s", cd.dbUser, cd.dbPwd, cd.dbName, cd.unixSocketPath)
+		dbPool, err := sql.Open("mssql", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	default:
+		dbURI := fmt.Sprintf("user=%s password=%s database=%s host=%s",
+			cd.dbUser, cd.dbPwd, cd.dbName, cd.unixSocketPath)
+		dbPool, err := sql.Open("pgx", dbURI)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+		return dbPool, nil
+	}
 }
 
-// GetDB creates a new connection using the cloudsql connector if the
-// INSTANCE_CONNECTION_NAME environment variable is set, otherwise it
-// connects to the local database using TCP.
-func GetDB() *sql.DB {
-	var (
-		dbUser                 = mustGetenv("DB_USER")                 // e.g. 'my-db-user'
-		dbPwd                  = mustGetenv("DB_PASS")                 // e.g. 'my-db-password'
-		dbName                 = mustGetenv("DB_NAME")                 // e.g. 'my-database'
-		instanceConnectionName = os.Getenv("INSTANCE_CONNECTION_NAME") // e.g. 'project:region:instance'
-		usePrivate             = os.Getenv("PRIVATE_IP")
-		dbTCPHost              = os.Getenv("INSTANCE_HOST") // "127.0.0.1")
-		dbPort                 = os.Getenv("DB_PORT")
-	)
-	cd := connectionDetails{
-		dbUser:                 dbUser,
-		dbPwd:                  dbPwd,
-		dbName:                 dbName,
-		instanceConnectionName: instanceConnectionName,
-		usePrivate:             usePrivate,
-		dbTCPHost:              dbTCPHost,
-		dbPort:                 dbPort,
+func connect(cd connectionDetails) (*sql.DB, error) {
+	switch {
+	case cd.unixSocketPath != "":
+		return connectUnixSocket(cd)
+	case cd.instanceConnectionName != "":
+		return connectWithConnector(cd)
+	default:
+		return connectTCPSocket(cd)
+	}
+}
+
+// configFromEnv builds a Config from the same environment variables
+// GetDB and GetDBFor have always read.
+func configFromEnv(engine string) Config {
+	cfg := Config{
+		Engine:                 engine,
+		DBName:                 mustGetenv("DB_NAME"),                 // e.g. 'my-database'
+		InstanceConnectionName: os.Getenv("INSTANCE_CONNECTION_NAME"), // e.g. 'project:region:instance'
+		TCPHost:                os.Getenv("INSTANCE_HOST"),            // e.g. "127.0.0.1"
+		TCPPort:                os.Getenv("DB_PORT"),
+		UnixSocketPath:         os.Getenv("INSTANCE_UNIX_SOCKET"), // e.g. '/cloudsql/project:region:instance'
+		IAMAuthN:               os.Getenv("IAM_AUTH") == "true",
+	}
+	if os.Getenv("PRIVATE_IP") != "" {
+		cfg.AutoIP = AutoIPPrivate
+	}
+	if cfg.IAMAuthN {
+		cfg.User = os.Getenv("DB_USER")
+	} else {
+		cfg.CredentialProvider = getCredentialProvider()
 	}
+	return cfg
+}
+
+// GetDBFor creates a new connection for the given database engine
+// ("postgres", "mysql" or "mssql"), using a Unix socket if the
+// INSTANCE_UNIX_SOCKET environment variable is set, otherwise the cloudsql
+// connector if INSTANCE_CONNECTION_NAME is set, otherwise it connects to
+// the local database using TCP. Only the first call of GetDB or GetDBFor
+// opens a pool; later calls return that same pool, whatever engine they
+// name. Use Open instead to configure a pool without env vars or to tune
+// it beyond what GetDBFor exposes.
+func GetDBFor(engine string) *sql.DB {
 	once.Do(func() {
-		var localdb *sql.DB
-		var err error
-		if cd.instanceConnectionName != "" {
-			localdb, err = connectWithConnector(cd)
-		} else {
-			localdb, err = connectTCPSocket(cd)
-		}
+		localdb, err := Open(context.Background(), configFromEnv(engine))
 		if err != nil {
 			log.Fatalf("Could not connect %v", err)
 		}
@@ -131,3 +298,17 @@ func GetDB() *sql.DB {
 	})
 	return db
 }
+
+// GetDB creates a new connection for the engine named by the DB_ENGINE
+// environment variable ("mysql" or "mssql"; Postgres when unset), using a
+// Unix socket if INSTANCE_UNIX_SOCKET is set, otherwise the cloudsql
+// connector if INSTANCE_CONNECTION_NAME is set, otherwise it connects to
+// the local database using TCP. It shares its pool with GetDBFor, which
+// takes the engine as an argument instead of reading DB_ENGINE.
+func GetDB() *sql.DB {
+	engine := os.Getenv("DB_ENGINE")
+	if engine == "" {
+		engine = enginePostgres
+	}
+	return GetDBFor(engine)
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataconnect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+// iamUserFromDefaultCredentials derives a Cloud SQL IAM database user from
+// the environment's Application Default Credentials service account
+// email, for use when IAM database authentication is enabled and DB_USER
+// was not supplied explicitly. The email is read from the client_email
+// field of the credentials JSON (a service account key); credentials that
+// carry no such JSON, such as those from the metadata server, give an
+// error.
+func iamUserFromDefaultCredentials(ctx context.Context) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx)
+	if err != nil {
+		return "", fmt.Errorf("google.FindDefaultCredentials: %w", err)
+	}
+	email, err := serviceAccountEmail(creds)
+	if err != nil {
+		return "", err
+	}
+	// Cloud SQL IAM database users for a service account drop the
+	// ".gserviceaccount.com" domain suffix from its email.
+	return strings.TrimSuffix(email, ".gserviceaccount.com"), nil
+}
+
+func serviceAccountEmail(creds *google.Credentials) (string, error) {
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if err := json.Unmarshal(creds.JSON, &sa); err != nil {
+		return "", fmt.Errorf("parsing default credentials: %w", err)
+	}
+	if sa.ClientEmail == "" {
+		return "", fmt.Errorf("default credentials do not include a client_email; IAM_AUTH requires a service account")
+	}
+	return sa.ClientEmail, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataconnect
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/nohe427/boilerplate-utils/dataconnect"
+
+var (
+	tracer = otel.Tracer(instrumentationName)
+	meter  = otel.Meter(instrumentationName)
+
+	connectLatency metric.Float64Histogram
+	connectErrors  metric.Int64Counter
+)
+
+func init() {
+	registerMetrics()
+}
+
+func registerMetrics() {
+	connectLatency, _ = meter.Float64Histogram(
+		"dataconnect.connect.duration",
+		metric.WithDescription("Time taken to open the database connection pool, in milliseconds."),
+		metric.WithUnit("ms"),
+	)
+	connectErrors, _ = meter.Int64Counter(
+		"dataconnect.connect.errors",
+		metric.WithDescription("Count of failed attempts to open the database connection pool."),
+	)
+}
+
+// EnableTracing registers exp as a span exporter for the dataconnect
+// package and installs it as the global OpenTelemetry TracerProvider.
+// Call it once during application startup, before GetDB or GetDBFor.
+func EnableTracing(exp sdktrace.SpanExporter) error {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	otel.SetTracerProvider(tp)
+	tracer = tp.Tracer(instrumentationName)
+	return nil
+}
+
+// EnableMetrics registers reader as a metric reader for the dataconnect
+// package and installs it as the global OpenTelemetry MeterProvider.
+// Call it once during application startup, before GetDB or GetDBFor.
+func EnableMetrics(reader sdkmetric.Reader) error {
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	otel.SetMeterProvider(mp)
+	meter = mp.Meter(instrumentationName)
+	registerMetrics()
+	return nil
+}
+
+// traceDial wraps a cloudsqlconn dial operation in a span tagged with the
+// instance connection name, so slow refreshes and dial failures surface
+// in whatever tracing backend EnableTracing was pointed at. cloudsqlconn
+// refreshes certificates transparently as part of Dial, so refresh
+// latency is captured within this span.
+func traceDial(ctx context.Context, instanceConnectionName string, dial func(context.Context) (net.Conn, error)) (net.Conn, error) {
+	ctx, span := tracer.Start(ctx, "dataconnect.Dial",
+		trace.WithAttributes(attribute.String("cloudsql.instance_connection_name", instanceConnectionName)))
+	defer span.End()
+
+	conn, err := dial(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return conn, err
+}
+
+// recordConnect records the outcome and latency of a GetDB/GetDBFor call.
+func recordConnect(ctx context.Context, start time.Time, engine string, err error) {
+	attrs := metric.WithAttributes(attribute.String("dataconnect.engine", engine))
+	connectLatency.Record(ctx, float64(time.Since(start).Milliseconds()), attrs)
+	if err != nil {
+		connectErrors.Add(ctx, 1, attrs)
+	}
+}